@@ -1,23 +1,74 @@
 require 'fileutils'
+require 'csv'
 require 'nokogiri'
 
 # Options
 romlist = false
+romlist_parts = []
+xsd = false
 output_file = 'arcade_roms_filtered.txt'
 output_xml = false
+output_chd = false
+output_ini = false
+ini_group = 'manufacturer'
 print_desc = false
 dryrun = false
+verbose = false
+quiet = false
+fail_no_desc = false
+fail_orphans = false
+fail_no_name = false
+max_name_length = false
+fail_warnings = false
+orphan_clones = false
+bios_list = false
+shared_crcs = false
 manufacturer = false
+manufacturer_aliases = false
+display_type = false
+exclude_preliminary = false
+min_roms = false
+min_players = false
+max_players = false
+drop_unknown_players = false
 skip_attrs = false
+year_report = false
+driver_report = false
+letter_report = false
+quote_all = false
+excel_bom = false
+trailer_comment = false
+per_letter = false
+per_manufacturer = false
+size_budget = false
 # Variables
 roms_total = 0
 rom_count = 0
 clones_count = 0
+total_bytes = 0
+rom_files = 0
+rom_files_bytes = 0
 roms_skipped = 0
+warnings = 0
 output = ''
+disks_output = ''
+folders = {}
 roms = {}
 clones = {}
+years = {}
+drivers = {}
+aliases = {}
+letters = {}
+letter_counts = {}
+makers = {}
+no_desc = []
+rom_crcs = {}
+crc_families = {}
 # Const
+RX_SIZE = /\A(\d+(?:\.\d+)?)\s*(KB|MB|GB)?\z/i
+SIZE_UNITS = { 'KB' => 1024, 'MB' => 1024 ** 2, 'GB' => 1024 ** 3 }
+VALUE_FLAGS = ['-p', '-xsd', '-o', '-x', '-chd', '-ini', '-ig', '-m', '-ma', '-dt', '-mr', '-minp', '-maxp', '-s', '-n', '-mm', '-sb', '-yr', '-dr', '-lc', '-nl']
+RX_XML_PROLOG = /\A(\xEF\xBB\xBF)?\s*(<\?xml[^>]*>\s*)?(<!DOCTYPE[^>]*>\s*)?/n
 HELP = <<eof
 
 Arcade ROMs Filter 1.0
@@ -29,15 +80,46 @@ The filter excludes by default:
 - Clones, except if they support a number of players different form the parent ROM
 
 Usage:
-  ruby arcade_roms_filter.rb [romlist] [-o [output.file] -x [xml.file] -pd -m [manufacturer] -s [attr1[,attrN]] -d]
+  ruby arcade_roms_filter.rb [romlist] [-p [part2[,partN]] -xsd [schema.xsd] -o [output.file] -x [xml.file] -chd [chd.file] -ini [ini.file] -ig [manufacturer|year] -pd -m [manufacturer] -ma [aliases.csv] -dt [vector|raster] -ep -mr [number] -minp [number] -maxp [number] -pu -s [attr1[,attrN]] -n [number] -mm [number] -sb [size] -yr [report.file] -dr [report.file] -lc [report.file] -qa -bom -tc -oc -b -dc -fd -fo -fn -nl [number] -fw -v -q -d]
   
 Arguments:
     romlist XML Dat file with the ROMs
+    -p  Comma-separated list of the next parts of a romlist split across several files
+    -xsd Validates the romlist against the given XML schema before filtering
     -o  Output file with the filtered ROMs
     -x  Creates a XML Dat file
-    -pd Print description of the rom and size (uncompressed)
+    -chd Creates a list of the filtered ROMs that have CHD disks, with the disk names and SHA1
+    -ini Creates a MAME custom folder INI file with the filtered ROMs
+    -ig Field used to group the ROMs in the INI file: manufacturer (default) or year
+    -pd Print description of the rom, size (uncompressed) and imperfect emulation features
     -m  Filter only ROMs of the given manufacturer (case-insensitive)
+    -ma CSV file mapping manufacturer names to a canonical name (raw,canonical)
+    -dt Filter only ROMs with the given display type (vector or raster)
+    -ep Exclude ROMs with preliminary driver status
+    -mr Filter only ROMs with at least N rom files
+    -minp Filter only ROMs for at least N players
+    -maxp Filter only ROMs for at most N players
+    -pu Exclude ROMs with unknown number of players when filtering by players
     -s  Skip ROMs that matches the comma-separated list of attributes (case-insensitive)
+    -n  Keep only the first N ROMs and clones for each starting letter
+    -mm Keep only the first N ROMs and clones for each manufacturer
+    -sb Select ROMs within the given size budget (uncompressed, e.g. 32GB), parents first by name
+    -yr Creates a CSV report with the number of parents and clones per year
+    -dr Creates a CSV report with the number of ROMs per driver source file
+    -lc Creates a CSV report with the number of filtered ROMs per starting letter
+    -qa Quotes every field of the CSV reports
+    -bom Writes the CSV reports with a UTF-8 BOM, for Excel
+    -tc Appends a `# N games, generated <date>` comment line to the CSV reports
+    -oc Lists only the clones whose parent ROM is not in the DAT file
+    -b  Lists only the BIOS files of the DAT file
+    -dc Lists only the ROMs of different parent families that share a ROM file CRC
+    -fd Fails if any parent ROM has no description, listing them
+    -fo Fails if any clone references a parent ROM not in the DAT file, listing them
+    -fn Fails if any game has an empty name, listing their line numbers
+    -nl Warns about game names longer than N characters
+    -fw Exits with an error status if any warning was printed
+    -v  Verbose mode. Lists the ROM names in the warnings
+    -q  Quiet mode. Prints only warnings and errors
     -d  Doesn't output any file. Prints output in the terminal
     -h  Display this help
 eof
@@ -45,22 +127,85 @@ eof
 # Get arguments
 unless ARGV.empty?
   ARGV.each_with_index { |item, i|
-    if item == "-o"
+    if item == "-p"
+      romlist_parts = ARGV[i+1].split(',')
+    elsif item == "-xsd"
+      xsd = ARGV[i+1]
+    elsif item == "-o"
       output_file = ARGV[i+1]
     elsif item == "-x"
       output_xml = ARGV[i+1]
+    elsif item == "-chd"
+      output_chd = ARGV[i+1]
+    elsif item == "-ini"
+      output_ini = ARGV[i+1]
+    elsif item == "-ig"
+      ini_group = ARGV[i+1].downcase
     elsif item == "-m"
       manufacturer = ARGV[i+1].downcase
+    elsif item == "-ma"
+      manufacturer_aliases = ARGV[i+1]
+    elsif item == "-dt"
+      display_type = ARGV[i+1].downcase
+    elsif item == "-ep"
+      exclude_preliminary = true
+    elsif item == "-mr"
+      min_roms = ARGV[i+1]
+    elsif item == "-minp"
+      min_players = ARGV[i+1]
+    elsif item == "-maxp"
+      max_players = ARGV[i+1]
+    elsif item == "-pu"
+      drop_unknown_players = true
     elsif item == "-pd"
       print_desc = true
+    elsif item == "-oc"
+      orphan_clones = true
+    elsif item == "-b"
+      bios_list = true
+    elsif item == "-dc"
+      shared_crcs = true
+    elsif item == "-fd"
+      fail_no_desc = true
+    elsif item == "-fo"
+      fail_orphans = true
+    elsif item == "-fn"
+      fail_no_name = true
+    elsif item == "-nl"
+      max_name_length = ARGV[i+1]
+    elsif item == "-fw"
+      fail_warnings = true
+    elsif item == "-v"
+      verbose = true
+    elsif item == "-q"
+      quiet = true
     elsif item == "-d"
       dryrun = true
     elsif item == "-s"
       skip_attrs = ARGV[i+1].downcase.split(',')
+    elsif item == "-n"
+      per_letter = ARGV[i+1]
+    elsif item == "-mm"
+      per_manufacturer = ARGV[i+1]
+    elsif item == "-sb"
+      size_budget = ARGV[i+1]
+    elsif item == "-yr"
+      year_report = ARGV[i+1]
+    elsif item == "-dr"
+      driver_report = ARGV[i+1]
+    elsif item == "-lc"
+      letter_report = ARGV[i+1]
+    elsif item == "-qa"
+      quote_all = true
+    elsif item == "-bom"
+      excel_bom = true
+    elsif item == "-tc"
+      trailer_comment = true
     elsif item == "-h"
       puts HELP
       exit
     else
+      next if i > 0 && VALUE_FLAGS.include?(ARGV[i-1])  # Value of an option, not the romlist
       if !romlist && File.exists?(item) && !File.directory?(item)
         romlist = item
       end
@@ -73,12 +218,70 @@ if !romlist
   exit
 end
 
+romlist_parts.each do |part|
+  if !File.exists?(part)
+    puts "ERROR: Romlist part \"#{part}\" not found. Type `ruby arcade_roms_filter.rb -h` for help."
+    exit 1
+  end
+end
+
+if display_type && !['vector', 'raster'].include?(display_type)
+  puts "ERROR: Invalid display type \"#{display_type}\", use vector or raster. Type `ruby arcade_roms_filter.rb -h` for help."
+  exit 1
+end
+
+if !['manufacturer', 'year'].include?(ini_group)
+  puts "ERROR: Invalid INI group \"#{ini_group}\", use manufacturer or year. Type `ruby arcade_roms_filter.rb -h` for help."
+  exit 1
+end
+
+if xsd && !File.exists?(xsd)
+  puts "ERROR: XML schema file not found. Type `ruby arcade_roms_filter.rb -h` for help."
+  exit 1
+end
+
+if manufacturer_aliases
+  if !File.exists?(manufacturer_aliases)
+    puts "ERROR: Manufacturer aliases file not found. Type `ruby arcade_roms_filter.rb -h` for help."
+    exit 1
+  end
+  begin
+    CSV.foreach(manufacturer_aliases) do |row|
+      next if row[0].to_s.strip.empty? || row[1].to_s.strip.empty?
+      aliases[row[0].strip.downcase] = row[1].strip
+    end
+  rescue CSV::MalformedCSVError => e
+    puts "ERROR: Invalid manufacturer aliases file (#{e.message}). Type `ruby arcade_roms_filter.rb -h` for help."
+    exit 1
+  end
+  manufacturer = aliases.fetch(manufacturer, manufacturer).downcase if manufacturer
+end
+
 # Functions
-def getRomSize(rom)
+def readRomlist(romlist, parts)
+    # Read as bytes, libxml2 takes the encoding from the first part's declaration
+    xml = File.binread(romlist)
+    # Only the first part has the XML declaration and doctype
+    parts.each do |part|
+      xml += File.binread(part).sub(RX_XML_PROLOG, '')
+    end
+    
+    return xml
+end
+
+def getRomBytes(rom)
     rom_bytes = rom.xpath('rom').to_a.inject(0) do |sum, r|
       sum + r['size'].to_i
     end
     
+    return rom_bytes
+end
+
+def getRomSize(rom)
+    return formatSize(getRomBytes(rom))
+end
+
+def formatSize(rom_bytes)
     rom_size = rom_bytes / 1024  # KB
     unit = 'KB'
     if rom_size.to_s.length > 3
@@ -89,8 +292,208 @@ def getRomSize(rom)
     return rom_size.to_s + ' ' + unit
 end
 
+def parseCount(value, flag)
+    return false unless value
+    if value !~ /\A\d+\z/ || value.to_i < 1
+      puts "ERROR: Invalid number \"#{value}\" for #{flag}. Type `ruby arcade_roms_filter.rb -h` for help."
+      exit 1
+    end
+    
+    return value.to_i
+end
+
+def parseSize(size)
+    match = RX_SIZE.match(size.strip)
+    return false unless match
+    
+    return (match[1].to_f * SIZE_UNITS.fetch(match[2].to_s.upcase, 1)).to_i
+end
+
+def generateCsv(header, rows, quote_all)
+    report = CSV.generate(:force_quotes => quote_all) do |csv|
+      csv << header
+      rows.each { |row| csv << row }
+    end
+    
+    return report
+end
+
+def getTrailer(count)
+    return "# #{count} games, generated #{Time.now.strftime('%Y-%m-%d')}" + $/
+end
+
+def getOrphanClones(doc, clones)
+    names = {}
+    doc.xpath('/datafile/game').each { |game| names[game['name']] = true }
+    orphans = []
+    clones.each do |cloneof, games|
+      orphans.concat games unless names.key?(cloneof)
+    end
+    
+    return orphans
+end
+
+def getSharedCrcs(doc)
+    crcs = {}
+    doc.xpath('/datafile/game').each do |game|
+      family = game['cloneof'] || game['name']
+      game.xpath('rom[@crc]').each do |file|
+        next if file['merge']  # Inherited from the parent or the BIOS
+        crc = file['crc'].downcase
+        crcs[crc] = {} unless crcs.key?(crc)
+        crcs[crc][family] = [] unless crcs[crc].key?(family)
+        crcs[crc][family].push game unless crcs[crc][family].include?(game)
+      end
+    end
+    
+    return crcs.select { |crc, families| families.length > 1 }
+end
+
+def getDescription(rom)
+    desc = rom.at('description')
+    return desc ? desc.content : ''
+end
+
+def getDisks(rom)
+    disks = ''
+    rom.xpath('disk').each do |disk|
+      disks += "    #{disk['name']} #{disk['sha1']}"
+      disks += ' (writable)' if disk['writable'] == 'yes'  # Hard drive image
+      disks += $/
+    end
+    
+    return disks.empty? ? disks : rom['name'] + $/ + disks
+end
+
+def getDisplayType(rom)
+    display = rom.at('display')
+    return display['type'].to_s if display
+    video = rom.at('video')  # Older DATs
+    return video ? video['screen'].to_s : ''
+end
+
+def getDriverStatus(rom)
+    driver = rom.at('driver')
+    return driver ? driver['status'].to_s : ''
+end
+
+def getFolder(rom, ini_group, aliases)
+    folder = ini_group == 'year' ? getYear(rom) : getManufacturer(rom, aliases)
+    folder = folder.gsub(/[\[\]]/, '').strip  # Brackets would break the section header
+    return folder.empty? ? 'Unknown' : folder
+end
+
+def getImperfect(rom)
+    imperfect = []
+    driver = rom.at('driver')
+    if driver
+      ['sound', 'graphic', 'color'].each do |attr|
+        imperfect.push attr if driver[attr] && driver[attr] != 'good'
+      end
+    end
+    rom.xpath('feature[@status]').each do |feature|  # MAME
+      imperfect.push feature['type'] unless imperfect.include?(feature['type'])
+    end
+    
+    return imperfect.empty? ? '' : " [imperfect: #{imperfect.join(',')}]"
+end
+
+def getLetter(rom)
+    letter = rom['name'].to_s[0].to_s.upcase  # Empty names go to '#'
+    return letter =~ /[A-Z]/ ? letter : '#'
+end
+
+def getManufacturer(rom, aliases)
+    manufacturer = rom.at('manufacturer')
+    manufacturer = manufacturer ? manufacturer.content.strip : ''
+    return aliases.fetch(manufacturer.downcase, manufacturer)
+end
+
+def inPlayersRange(rom, min_players, max_players, drop_unknown)
+    input = rom.at('input')
+    return !drop_unknown unless input && input['players']
+    
+    players = input['players'].to_i
+    return false if min_players && players < min_players
+    return false if max_players && players > max_players
+    return true
+end
+
+def getYear(rom)
+    year = rom.at('year')
+    return year ? year.content.strip : rom['year'].to_s.strip  # Older DATs use an attribute
+end
+
+min_roms = parseCount(min_roms, '-mr')
+min_players = parseCount(min_players, '-minp')
+max_players = parseCount(max_players, '-maxp')
+per_letter = parseCount(per_letter, '-n')
+per_manufacturer = parseCount(per_manufacturer, '-mm')
+max_name_length = parseCount(max_name_length, '-nl')
+
+if size_budget
+  size_budget_bytes = parseSize(size_budget)
+  if !size_budget_bytes
+    puts "ERROR: Invalid size budget \"#{size_budget}\". Type `ruby arcade_roms_filter.rb -h` for help."
+    exit 1
+  end
+end
+
 # Do the magic
-doc = File.open(romlist) { |f| Nokogiri::XML(f) }
+if romlist_parts.empty?
+  doc = File.open(romlist) { |f| Nokogiri::XML(f) }
+  # Nokogiri recovers from XML errors, so some games may be missing or broken
+  errors = doc.errors.select { |e| e.error? || e.fatal? }
+  if errors.length > 0
+    puts "Warning: Romlist has #{errors.length} XML error(s), some ROMs may be missing or broken"
+    warnings += 1
+    puts errors if verbose
+  end
+else
+  doc = Nokogiri::XML(readRomlist(romlist, romlist_parts))
+  # Nokogiri drops anything after the first root element without failing
+  errors = doc.errors.select { |e| e.error? || e.fatal? }
+  if errors.length > 0
+    puts "ERROR: Romlist parts do not form a single XML document:"
+    puts errors
+    exit 1
+  end
+end
+if xsd
+  begin
+    schema = File.open(xsd) { |f| Nokogiri::XML::Schema(f) }
+  rescue Nokogiri::XML::SyntaxError => e
+    puts "ERROR: Invalid XML schema file (#{e.message.strip}). Type `ruby arcade_roms_filter.rb -h` for help."
+    exit 1
+  end
+  errors = schema.validate(doc)
+  if errors.length > 0
+    puts "ERROR: Romlist does not validate against \"#{xsd}\":"
+    puts errors
+    exit 1
+  end
+end
+# Bad tooling can leave a BOM at the start of a name
+doc.xpath('/datafile/game').each do |game|
+  ['name', 'cloneof', 'romof'].each do |attr|
+    game[attr] = game[attr].sub(/\A\uFEFF/, '') if game[attr] =~ /\A\uFEFF/
+  end
+end
+if max_name_length
+  long_names = doc.xpath('/datafile/game').select { |game| game['name'].to_s.length > max_name_length }
+  if long_names.length > 0
+    puts "Warning: #{long_names.length} game name(s) longer than #{max_name_length} characters"
+    warnings += 1
+    puts long_names.map { |game| "    #{game.line}: #{game['name']}" } if verbose
+  end
+end
+if fail_no_name
+  no_name = doc.xpath('/datafile/game').select { |game| game['name'].to_s.strip.empty? }
+  if no_name.length > 0
+    puts "ERROR: #{no_name.length} game(s) without name at line(s): " + no_name.map { |game| game.line }.join(", ")
+    exit 1
+  end
+end
 if output_xml
   builder = Nokogiri::XML::Builder.new do |xml|
      xml.doc.create_internal_subset(
@@ -114,6 +517,24 @@ end
 doc.xpath('/datafile/game[not(@isbios)]').each do |game|
   roms_total += 1
   cloneof = game['cloneof']
+  if cloneof && cloneof == game['name']
+    puts "Warning: ROM #{cloneof} is a clone of itself, treated as parent"
+    warnings += 1
+    game.remove_attribute('cloneof')
+    game.remove_attribute('romof') if game['romof'] == game['name']
+    cloneof = nil
+  end
+  if year_report
+    year = getYear(game)
+    year = 'unknown' if year.empty?
+    years[year] = [0, 0] unless years.key?(year)
+    years[year][cloneof ? 1 : 0] += 1
+  end
+  if driver_report
+    driver = game['sourcefile'].to_s.strip
+    driver = 'unknown' if driver.empty?
+    drivers[driver] = drivers[driver].to_i + 1
+  end
   if cloneof
     if !clones.key?(cloneof)
       clones[cloneof] = []
@@ -122,44 +543,165 @@ doc.xpath('/datafile/game[not(@isbios)]').each do |game|
   else
     next if game.key?('romof')  # Skip Neo Geo roms
     if skip_attrs
-      descr = game.at('description').content.downcase
+      descr = getDescription(game).downcase
       if skip_attrs.any? { |word| descr.include?(word) }
         roms_skipped += 1
         next
       end
     end
+    no_desc.push game['name'] if getDescription(game).strip.empty?
     roms[game['name']] = game
   end
 end
 
+if fail_no_desc && no_desc.length > 0
+  puts "ERROR: #{no_desc.length} parent ROM(s) without description: " + no_desc.join(", ")
+  exit 1
+end
+
+if fail_orphans
+  orphans = getOrphanClones(doc, clones)
+  if orphans.length > 0
+    puts "ERROR: #{orphans.length} clone(s) of a parent ROM not in the DAT file: " + orphans.map { |clone| "#{clone['name']} (#{clone['cloneof']})" }.join(", ")
+    exit 1
+  end
+end
+
+# The BIOS files, orphan clones or games sharing CRCs take the place of the parent ROMs
+if bios_list
+  roms = {}
+  doc.xpath('/datafile/game[@isbios="yes"]').each { |game| roms[game['name']] = game }
+  clones = {}
+elsif orphan_clones
+  roms = {}
+  getOrphanClones(doc, clones).each { |clone| roms[clone['name']] = clone }
+  clones = {}
+elsif shared_crcs
+  roms = {}
+  crc_families = getSharedCrcs(doc)
+  crc_families.each_value do |families|
+    families.each_value { |games| games.each { |game| roms[game['name']] = game } }
+  end
+  clones = {}
+end
+
+# Select the parent ROMs and their valid clones
+selection = []
 roms.each do |key, rom|
-  next if manufacturer && rom.at('manufacturer').content.downcase != manufacturer
+  maker = getManufacturer(rom, aliases).downcase
+  next if manufacturer && maker != manufacturer
+  next if display_type && getDisplayType(rom).downcase != display_type
+  next if exclude_preliminary && getDriverStatus(rom) == 'preliminary'
+  next if min_roms && rom.xpath('rom').length < min_roms
+  if min_players || max_players
+    next unless inPlayersRange(rom, min_players, max_players, drop_unknown_players)
+  end
+  letter = getLetter(rom)
+  next if per_letter && letters[letter].to_i >= per_letter
+  next if per_manufacturer && makers[maker].to_i >= per_manufacturer
+  letters[letter] = letters[letter].to_i + 1
+  makers[maker] = makers[maker].to_i + 1
+
+  valid_clones = []
+  if clones.key?(key)
+    clones[key].each do |clone|
+      next if exclude_preliminary && getDriverStatus(clone) == 'preliminary'
+      next if min_roms && clone.xpath('rom').length < min_roms
+      if min_players || max_players
+        next unless inPlayersRange(clone, min_players, max_players, drop_unknown_players)
+      end
+      next unless getDescription(clone) =~ /\bPlayers\b/i  # Valid clone
+      clone_letter = getLetter(clone)
+      clone_maker = getManufacturer(clone, aliases).downcase
+      next if per_letter && letters[clone_letter].to_i >= per_letter
+      next if per_manufacturer && makers[clone_maker].to_i >= per_manufacturer
+      letters[clone_letter] = letters[clone_letter].to_i + 1
+      makers[clone_maker] = makers[clone_maker].to_i + 1
+      valid_clones.push clone
+    end
+  end
+  selection.push [rom, valid_clones]
+end
+
+if size_budget
+  # Parents first by name order, then their clones with the space left.
+  # Each pass stops at the first game that does not fit
+  in_budget = {}
+  by_name = selection.sort_by { |rom, valid_clones| rom['name'] }
+  by_name.each do |rom, valid_clones|
+    rom_bytes = getRomBytes(rom)
+    break if total_bytes + rom_bytes > size_budget_bytes
+    total_bytes += rom_bytes
+    in_budget[rom['name']] = true
+  end
+  budget_full = false
+  by_name.each do |rom, valid_clones|
+    next unless in_budget.key?(rom['name'])
+    valid_clones.each do |clone|
+      clone_bytes = getRomBytes(clone)
+      budget_full = total_bytes + clone_bytes > size_budget_bytes
+      break if budget_full
+      total_bytes += clone_bytes
+      in_budget[clone['name']] = true
+    end
+    break if budget_full
+  end
+  selection = selection.select { |rom, valid_clones| in_budget.key?(rom['name']) }
+  selection.each { |rom, valid_clones| valid_clones.select! { |clone| in_budget.key?(clone['name']) } }
+end
+
+# ROM files of the filtered ROMs and clones, shared files counted once by CRC
+selection.each do |rom, valid_clones|
+  ([rom] + valid_clones).each do |game|
+    game.xpath('rom').each do |file|
+      rom_files += 1
+      rom_crcs[(file['crc'] || file['name']).to_s.downcase] = true
+    end
+    rom_files_bytes += getRomBytes(game)
+  end
+end
 
+selection.each do |rom, valid_clones|
   output += rom['name']
   if print_desc
-    output += ' ' * (12-rom['name'].length) + ' -- ' + rom.at('description').content
+    output += ' ' * (12-rom['name'].length) + ' -- ' + getDescription(rom)
     output += " (#{getRomSize(rom)})"
+    output += getImperfect(rom)
   end
   output += $/
   rom_count += 1
-  if clones.key?(key)
-    clones[key].each do |clone|
-      desc = clone.at('description').content
-      if desc =~ /\bPlayers\b/i  # Valid clone
-        if print_desc
-          output += '    '
-        end
-        output += clone['name']
-        if print_desc
-          output += ' ' * (16-clone['name'].length) + ' -- ' + desc
-          output += " (#{getRomSize(rom)})"
-        end
-        output += $/
-        clones_count += 1
-        if output_xml
-          dat.root.add_child clone
-        end
-      end
+  letter_counts[getLetter(rom)] = letter_counts[getLetter(rom)].to_i + 1
+  if output_chd
+    disks_output += getDisks(rom)
+  end
+  if output_ini
+    folder = getFolder(rom, ini_group, aliases)
+    folders[folder] = [] unless folders.key?(folder)
+    folders[folder].push rom['name']
+  end
+  valid_clones.each do |clone|
+    if print_desc
+      output += '    '
+    end
+    output += clone['name']
+    if print_desc
+      output += ' ' * (16-clone['name'].length) + ' -- ' + getDescription(clone)
+      output += " (#{getRomSize(clone)})"
+      output += getImperfect(clone)
+    end
+    output += $/
+    clones_count += 1
+    letter_counts[getLetter(clone)] = letter_counts[getLetter(clone)].to_i + 1
+    if output_chd
+      disks_output += getDisks(clone)
+    end
+    if output_ini
+      folder = getFolder(clone, ini_group, aliases)
+      folders[folder] = [] unless folders.key?(folder)
+      folders[folder].push clone['name']
+    end
+    if output_xml
+      dat.root.add_child clone
     end
   end
   if output_xml
@@ -167,8 +709,58 @@ roms.each do |key, rom|
   end
 end
 
+if output_ini
+  ini = "[FOLDER_SETTINGS]" + $/
+  ini += "RootFolderIcon custom" + $/
+  ini += "SubFolderIcon custom" + $/ + $/
+  ini += "[ROOT_FOLDER]" + $/ + $/
+  folders.keys.sort.each do |folder|
+    ini += "[#{folder}]" + $/
+    ini += folders[folder].join($/) + $/ + $/
+  end
+end
+
+if year_report
+  rows = years.keys.sort.map { |year| [year] + years[year] }
+  report = generateCsv(['year', 'parent_count', 'clone_count'], rows, quote_all)
+  report += getTrailer(roms_total) if trailer_comment
+end
+
+if driver_report
+  # Drivers with most ROMs first
+  rows = drivers.sort_by { |driver, count| [-count, driver] }
+  drivers_csv = generateCsv(['sourcefile', 'game_count'], rows, quote_all)
+  drivers_csv += getTrailer(roms_total) if trailer_comment
+end
+
+if letter_report
+  rows = (['#'] + ('A'..'Z').to_a).map { |letter| [letter, letter_counts[letter].to_i] }
+  letters_csv = generateCsv(['letter', 'count'], rows, quote_all)
+  letters_csv += getTrailer(rom_count + clones_count) if trailer_comment
+end
+
 if dryrun
   puts output
+  if output_chd
+    puts '', 'CHD list:', ''
+    puts disks_output
+  end
+  if output_ini
+    puts '', 'MAME custom folder:', ''
+    puts ini
+  end
+  if year_report
+    puts '', 'Year report:', ''
+    puts report
+  end
+  if driver_report
+    puts '', 'Driver report:', ''
+    puts drivers_csv
+  end
+  if letter_report
+    puts '', 'Letter report:', ''
+    puts letters_csv
+  end
 else
   open(output_file, 'w') { |f|
     f.puts output
@@ -176,14 +768,64 @@ else
   if output_xml
     File.write(output_xml, dat.to_xml)
   end
+  if output_chd
+    File.write(output_chd, disks_output)
+  end
+  if output_ini
+    File.write(output_ini, ini)
+  end
+  csv_bom = excel_bom ? "\uFEFF" : ''
+  if year_report
+    File.write(year_report, csv_bom + report)
+  end
+  if driver_report
+    File.write(driver_report, csv_bom + drivers_csv)
+  end
+  if letter_report
+    File.write(letter_report, csv_bom + letters_csv)
+  end
 end
 
-puts "ROMs in DAT file: #{roms_total}"
-puts "Found #{rom_count} roms and #{clones_count} valid clones (#{rom_count+clones_count} total)"
-if skip_attrs
+crc_families.keys.sort.each do |crc|
+  puts "CRC #{crc} shared by: " + crc_families[crc].keys.join(", ")
+end
+unless quiet
+  puts "ROMs in DAT file: #{roms_total}"
+  puts "Found #{rom_count} roms and #{clones_count} valid clones (#{rom_count+clones_count} total)"
+  puts "ROM files: #{rom_files} (#{rom_crcs.length} distinct), #{formatSize(rom_files_bytes)} (uncompressed)"
+  if size_budget
+    puts "Selected ROMs use #{formatSize(total_bytes)} (uncompressed) of the #{size_budget} budget"
+  end
+end
+if no_desc.length > 0
+  warning = "Warning: #{no_desc.length} parent ROM(s) without description"
+  warning += ": " + no_desc.join(", ") if verbose
+  puts warning
+  warnings += 1
+end
+if skip_attrs && !quiet
   puts "Skipped #{roms_skipped} ROMs that matched criteria \"#{skip_attrs.join(', ')}\""
 end
-unless dryrun
+unless dryrun || quiet
   puts $/
   puts "Created file \"#{output_file}\" with filtered ROM list"
-end
\ No newline at end of file
+  if output_chd
+    puts "Created file \"#{output_chd}\" with the CHD list"
+  end
+  if output_ini
+    puts "Created file \"#{output_ini}\" with the MAME custom folder"
+  end
+  if year_report
+    puts "Created file \"#{year_report}\" with the year report"
+  end
+  if driver_report
+    puts "Created file \"#{driver_report}\" with the driver report"
+  end
+  if letter_report
+    puts "Created file \"#{letter_report}\" with the letter report"
+  end
+end
+if fail_warnings && warnings > 0
+  puts "ERROR: #{warnings} warning(s) found"
+  exit 1
+end
@@ -0,0 +1,75 @@
+require 'fileutils'
+require 'open3'
+require 'rbconfig'
+
+# Runs arcade_roms_filter.rb over the fixture DAT files and compares the output
+# (and the CSV files written to fixtures/tmp) with the files in fixtures/expected.
+# Usage: ruby fixtures/check.rb [-u]
+#   -u  Rewrites the expected files with the current output
+update = ARGV.include?('-u')
+failed = 0
+# Const
+TMP_DIR = 'fixtures/tmp'
+CASES = [
+  ['default',            'fixtures/romlist.dat -d'],
+  ['describe',           'fixtures/romlist.dat -pd -v -d'],
+  ['chd',                'fixtures/romlist.dat -chd chd.txt -d'],
+  ['ini',                'fixtures/romlist.dat -ini custom.ini -ma fixtures/aliases.csv -d'],
+  ['ini_year',           'fixtures/romlist.dat -ini custom.ini -ig year -d'],
+  ['year_report',        'fixtures/romlist.dat -yr years.csv -d'],
+  ['driver_report',      'fixtures/romlist.dat -dr drivers.csv -d'],
+  ['letter_report',      'fixtures/romlist.dat -lc letters.csv -d'],
+  ['quote_all',          'fixtures/romlist.dat -yr years.csv -qa -d'],
+  ['csv_files',          'fixtures/romlist.dat -o fixtures/tmp/list.txt -yr fixtures/tmp/years.csv -lc fixtures/tmp/letters.csv -bom -tc -q'],
+  ['orphans',            'fixtures/romlist.dat -oc -d'],
+  ['bios',               'fixtures/romlist.dat -b -d'],
+  ['shared_crcs',        'fixtures/romlist.dat -dc -d'],
+  ['fail_no_desc',       'fixtures/romlist.dat -o fixtures/tmp/list.txt -fd'],
+  ['fail_orphans',       'fixtures/romlist.dat -o fixtures/tmp/list.txt -fo'],
+  ['fail_warnings',      'fixtures/romlist.dat -fw -q -d'],
+  ['display',            'fixtures/romlist.dat -dt vector -d'],
+  ['display_invalid',    'fixtures/romlist.dat -dt vectro -d'],
+  ['preliminary',        'fixtures/romlist.dat -ep -d'],
+  ['min_players',        'fixtures/romlist.dat -minp 4 -d'],
+  ['min_players_known',  'fixtures/romlist.dat -minp 4 -pu -d'],
+  ['max_players',        'fixtures/romlist.dat -maxp 2 -d'],
+  ['min_roms',           'fixtures/romlist.dat -mr 2 -d'],
+  ['per_letter',         'fixtures/romlist.dat -n 1 -d'],
+  ['per_letter_invalid', 'fixtures/romlist.dat -n 0 -d'],
+  ['per_manufacturer',   'fixtures/romlist.dat -mm 1 -d'],
+  ['manufacturer',       'fixtures/romlist.dat -m atari -ma fixtures/aliases.csv -d'],
+  ['skip',               'fixtures/romlist.dat -s revision,japan -d'],
+  ['size_budget',        'fixtures/romlist.dat -sb 2MB -pd -d'],
+  ['no_name',            'fixtures/no_name.dat -d'],
+  ['no_name_fail',       'fixtures/no_name.dat -o fixtures/tmp/list.txt -fn'],
+  ['parts',              'fixtures/latin1_part1.dat -p fixtures/latin1_part2.dat -pd -d']
+]
+
+Dir.chdir(File.expand_path('..', File.dirname(__FILE__))) do
+  CASES.each do |name, args|
+    FileUtils.rm_rf(TMP_DIR)
+    FileUtils.mkdir_p(TMP_DIR)
+    out, status = Open3.capture2e(RbConfig.ruby, 'arcade_roms_filter.rb', *args.split(' '))
+    out = out.force_encoding('BINARY') + "exit status #{status.exitstatus}" + $/
+    Dir.glob(File.join(TMP_DIR, '*')).sort.each do |file|
+      out += "--- #{File.basename(file)}" + $/ + File.binread(file)
+    end
+    out = out.gsub(/generated \d{4}-\d\d-\d\d/, 'generated YYYY-MM-DD')
+    expected_file = "fixtures/expected/#{name}.txt"
+    if update
+      File.open(expected_file, 'wb') { |f| f.write out }
+    elsif !File.exists?(expected_file) || File.binread(expected_file) != out
+      puts "FAIL #{name}: ruby arcade_roms_filter.rb #{args}"
+      puts out
+      failed += 1
+    end
+  end
+  FileUtils.rm_rf(TMP_DIR)
+end
+
+if update
+  puts "Updated #{CASES.length} expected files"
+else
+  puts "#{CASES.length - failed} of #{CASES.length} cases passed"
+  exit 1 if failed > 0
+end
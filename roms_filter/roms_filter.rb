@@ -5,6 +5,7 @@ working_dir = '.'
 output = '_selection.txt'
 $analyze = false
 $skip_attrs = false
+$skip_tags = false
 # Variables
 rom_count = 0
 tmp_roms = []
@@ -18,6 +19,7 @@ RX_IS_WORLD = /\(.*World[^)]*\)/
 RX_IS_EUROPE = /\(.*Europe[^)]*\)/
 RX_GAMECUBE = /\(.*GameCube[^)]*\)/
 RX_HAS_VERSION_NUMBER = /\((Rev|v)[^)]*\)/
+PROTOTYPE_TAGS = ['proto', 'beta', 'demo', 'sample']
 RX_NAME = /([^(]+)/
 HELP = <<eof
 ROMs filter 1.0
@@ -29,13 +31,15 @@ The filter selects one ROM from a group of ROMs with the same name using the fol
 - GameCube reedition
 
 Usage:
-    ruby roms_filter.rb -t [targetdir] [-o [output.file] -a -s [attr1[,attrN]]]
+    ruby roms_filter.rb -t [targetdir] [-o [output.file] -a -s [attr1[,attrN]] -sp [tag1[,tagN]]]
 
 Arguments:
     -t  Target dir with the zipped ROMs
     -o  Output file were write the filtered ROMs
     -a  Analyze mode. Prints output in the terminal
-    -s  Skip ROMs that matches the comma-separated list of attributes
+    -s  Skip ROMs that matches the comma-separated list of attributes (case-insensitive)
+    -sp Skip ROMs with an attribute starting with one of the comma-separated tags
+        (case-insensitive). Default: Proto,Beta,Demo,Sample
     -h  Display this help
 eof
 
@@ -48,7 +52,10 @@ unless ARGV.empty?
     elsif item == "-a"
       $analyze = true
     elsif item == "-s"
-      $skip_attrs = ARGV[i+1].split(',')
+      $skip_attrs = ARGV[i+1].downcase.split(',')
+    elsif item == "-sp"
+      tags = ARGV[i+1]
+      $skip_tags = tags && tags !~ /^-/ ? tags.downcase.split(',') : PROTOTYPE_TAGS
     elsif item == "-h"
       puts HELP
       exit
@@ -93,9 +100,14 @@ def filter_roms(roms, roms_attrs)
       points[i] += 0.1 * revs[attrs[0]]
     end
     if $skip_attrs
-      skip_intersect = attrs & $skip_attrs
+      skip_intersect = attrs.map { |attr| attr.downcase } & $skip_attrs
       points[i] = -1 if skip_intersect.length > 0      
     end
+    if $skip_tags
+      # Matches (Proto), (Prototype), (Beta 2)...
+      skip_tag = attrs.any? { |attr| $skip_tags.any? { |tag| attr.downcase.start_with?(tag) } }
+      points[i] = -1 if skip_tag
+    end
     
     chosen = i if points[i] >= points[chosen]
   }